@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// CronEntry is a single parsed line of a crontab: either a standard
+// 5-field schedule or an "@shortcut" alias, plus any ENV=VALUE
+// assignments that precede it in the file are skipped rather than
+// reported as entries.
+type CronEntry struct {
+	Minute  string `json:"minute" yaml:"minute"`
+	Hour    string `json:"hour" yaml:"hour"`
+	Dom     string `json:"dom" yaml:"dom"`
+	Month   string `json:"month" yaml:"month"`
+	Dow     string `json:"dow" yaml:"dow"`
+	Command string `json:"command" yaml:"command"`
+	// Comment is the text after a trailing "# ..." on the job's line,
+	// if any; it's what the job's shell itself treats as a comment.
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Raw     string `json:"raw" yaml:"raw"`
+
+	// NextRuns is populated by callers that want upcoming fire times
+	// included in a report; ParseCrontab leaves it empty.
+	NextRuns []time.Time `json:"next_runs,omitempty" yaml:"next_runs,omitempty"`
+}
+
+// shortcuts maps the "@shortcut" aliases crontab accepts in place of
+// the 5 time fields. @reboot has no time-field equivalent, so it is
+// left to ParseCrontab to special-case.
+var shortcuts = map[string][5]string{
+	"@yearly":   {"0", "0", "1", "1", "*"},
+	"@annually": {"0", "0", "1", "1", "*"},
+	"@monthly":  {"0", "0", "1", "*", "*"},
+	"@weekly":   {"0", "0", "*", "*", "0"},
+	"@daily":    {"0", "0", "*", "*", "*"},
+	"@midnight": {"0", "0", "*", "*", "*"},
+	"@hourly":   {"0", "*", "*", "*", "*"},
+}
+
+// ParseCrontab parses the output of `crontab -l` into CronEntry values.
+// Blank lines, full-line comments, and ENV=VALUE assignments (e.g.
+// SHELL=/bin/bash, MAILTO=root) are skipped rather than reported as
+// entries.
+func ParseCrontab(raw []byte) []CronEntry {
+	var entries []CronEntry
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if isEnvAssignment(trimmed) {
+			continue
+		}
+
+		entry, ok := parseCronLine(trimmed)
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// isEnvAssignment reports whether line looks like NAME=VALUE, the form
+// crontab uses for SHELL, PATH, MAILTO, TZ, and similar settings.
+func isEnvAssignment(line string) bool {
+	eq := strings.Index(line, "=")
+	if eq <= 0 {
+		return false
+	}
+	name := line[:eq]
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			continue
+		}
+		if i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	// A schedule field never contains a space before the first token,
+	// so "0 1 * * * cmd" never reaches here, but guard against a
+	// command line whose first word happens to contain "=" (e.g. a
+	// shell assignment passed as the job itself) by requiring no
+	// whitespace in the name.
+	return !strings.ContainsAny(name, " \t")
+}
+
+// parseCronLine splits a single non-comment, non-env crontab line into
+// a CronEntry, handling both "@shortcut command" and the standard
+// 5-field "m h dom mon dow command" forms.
+func parseCronLine(line string) (CronEntry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return CronEntry{}, false
+	}
+
+	entry := CronEntry{Raw: line}
+
+	if strings.HasPrefix(fields[0], "@") {
+		if fields[0] == "@reboot" {
+			entry.Minute = "@reboot"
+			entry.Command, entry.Comment = splitCommandComment(fields[1:])
+			return entry, true
+		}
+
+		spec, ok := shortcuts[fields[0]]
+		if !ok {
+			return CronEntry{}, false
+		}
+		entry.Minute, entry.Hour, entry.Dom, entry.Month, entry.Dow = spec[0], spec[1], spec[2], spec[3], spec[4]
+		entry.Command, entry.Comment = splitCommandComment(fields[1:])
+		return entry, true
+	}
+
+	if len(fields) < 6 {
+		return CronEntry{}, false
+	}
+
+	entry.Minute, entry.Hour, entry.Dom, entry.Month, entry.Dow = fields[0], fields[1], fields[2], fields[3], fields[4]
+	entry.Command, entry.Comment = splitCommandComment(fields[5:])
+	return entry, true
+}
+
+// splitCommandComment splits a job's command fields on the first token
+// starting with "#": since crontab hands the whole remainder of the
+// line to the user's shell, a "# ..." trailing a command is itself a
+// shell comment, not part of what runs.
+func splitCommandComment(fields []string) (command, comment string) {
+	for i, f := range fields {
+		if strings.HasPrefix(f, "#") {
+			comment = strings.TrimPrefix(strings.Join(fields[i:], " "), "#")
+			return strings.Join(fields[:i], " "), strings.TrimSpace(comment)
+		}
+	}
+	return strings.Join(fields, " "), ""
+}
+
+// detectTZ scans raw crontab lines for a "TZ=" assignment (the form
+// crontab -l emits when a user's crontab sets a timezone) and returns
+// its value, or "" if none is present.
+func detectTZ(raw []byte) string {
+	for _, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(trimmed, "TZ="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// GetCronEntries fetches the remote crontab and parses it into
+// structured entries.
+func (s *Session) GetCronEntries(ctx context.Context) ([]CronEntry, error) {
+	raw, err := s.GetCrontab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCrontab(raw), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCrontab(t *testing.T) {
+	raw := []byte(`# comment line
+SHELL=/bin/bash
+MAILTO=""
+0 1 * * * /usr/bin/backup.sh
+*/15 * * * 1-5 /usr/bin/check.sh --flag # check disk usage
+@daily /usr/bin/rotate-logs
+@reboot /usr/bin/startup.sh
+`)
+
+	entries := ParseCrontab(raw)
+
+	want := []CronEntry{
+		{Minute: "0", Hour: "1", Dom: "*", Month: "*", Dow: "*", Command: "/usr/bin/backup.sh", Raw: "0 1 * * * /usr/bin/backup.sh"},
+		{Minute: "*/15", Hour: "*", Dom: "*", Month: "*", Dow: "1-5", Command: "/usr/bin/check.sh --flag", Comment: "check disk usage", Raw: "*/15 * * * 1-5 /usr/bin/check.sh --flag # check disk usage"},
+		{Minute: "0", Hour: "0", Dom: "*", Month: "*", Dow: "*", Command: "/usr/bin/rotate-logs", Raw: "@daily /usr/bin/rotate-logs"},
+		{Minute: "@reboot", Command: "/usr/bin/startup.sh", Raw: "@reboot /usr/bin/startup.sh"},
+	}
+
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("ParseCrontab() = %#v, want %#v", entries, want)
+	}
+}
@@ -0,0 +1,284 @@
+// Package inventory discovers SSH destinations from sources other than
+// the tool's own JSON config file: OpenSSH's ~/.ssh/config and
+// Ansible-style INI inventories.
+package inventory
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Dest is one host discovered from an external inventory, with enough
+// fields to build a connection: the caller maps this onto its own
+// destination type.
+type Dest struct {
+	Host           string
+	User           string
+	Port           string
+	IdentityFile   string
+	Jump           string
+	KnownHostsFile string
+	// Groups lists the Ansible inventory groups this host belongs to;
+	// empty for hosts discovered from an SSH config.
+	Groups []string
+}
+
+// sshDirective is one "Keyword value" line from an SSH config, tagged
+// with the Host patterns of the block it appeared in.
+type sshDirective struct {
+	patterns []string
+	keyword  string
+	value    string
+}
+
+// LoadSSHConfig parses an OpenSSH-style config (as read from
+// ~/.ssh/config) and returns one Dest per literal (non-wildcard) Host
+// alias, with HostName, User, Port, IdentityFile, ProxyJump, and
+// UserKnownHostsFile resolved by the same "first obtained value wins,
+// most specific pattern isn't required to match" cascading rule ssh
+// itself uses.
+func LoadSSHConfig(r io.Reader) ([]Dest, error) {
+	directives, err := parseSSHConfig(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []string
+	seen := map[string]bool{}
+	for _, d := range directives {
+		for _, p := range d.patterns {
+			if isSSHPattern(p) || seen[p] {
+				continue
+			}
+			seen[p] = true
+			aliases = append(aliases, p)
+		}
+	}
+
+	dests := make([]Dest, 0, len(aliases))
+	for _, alias := range aliases {
+		dest := Dest{Host: alias}
+		resolved := map[string]bool{}
+
+		for _, d := range directives {
+			if resolved[d.keyword] || !matchesAny(d.patterns, alias) {
+				continue
+			}
+			resolved[d.keyword] = true
+
+			switch d.keyword {
+			case "hostname":
+				dest.Host = d.value
+			case "user":
+				dest.User = d.value
+			case "port":
+				dest.Port = d.value
+			case "identityfile":
+				dest.IdentityFile = d.value
+			case "proxyjump":
+				dest.Jump = d.value
+			case "userknownhostsfile":
+				dest.KnownHostsFile = d.value
+			}
+		}
+
+		dests = append(dests, dest)
+	}
+
+	return dests, nil
+}
+
+// parseSSHConfig reads "Keyword value" (or "Keyword=value") lines,
+// tagging each with the space-separated Host patterns of its block.
+func parseSSHConfig(r io.Reader) ([]sshDirective, error) {
+	var directives []sshDirective
+	var patterns []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, value, ok := splitSSHConfigLine(line)
+		if !ok {
+			continue
+		}
+
+		if strings.EqualFold(keyword, "host") {
+			patterns = strings.Fields(value)
+			continue
+		}
+
+		directives = append(directives, sshDirective{
+			patterns: patterns,
+			keyword:  strings.ToLower(keyword),
+			value:    value,
+		})
+	}
+
+	return directives, scanner.Err()
+}
+
+// splitSSHConfigLine splits "Keyword value", "Keyword=value", or
+// "Keyword = value" into its keyword and value.
+func splitSSHConfigLine(line string) (keyword, value string, ok bool) {
+	line = strings.TrimSpace(strings.ReplaceAll(line, "\t", " "))
+	if eq := strings.Index(line, "="); eq >= 0 && !strings.Contains(line[:eq], " ") {
+		return strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:]), true
+	}
+
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], strings.TrimSpace(fields[1]), true
+}
+
+// isSSHPattern reports whether p contains ssh_config glob metacharacters,
+// meaning it's a default-setting pattern (e.g. "*", "*.internal") rather
+// than a literal host alias.
+func isSSHPattern(p string) bool {
+	return strings.ContainsAny(p, "*?")
+}
+
+func matchesAny(patterns []string, alias string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, alias); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadInventory parses an Ansible-style INI inventory: "[group]"
+// sections listing one host (with optional inline "key=value" host
+// vars) per line, plus optional "[group:vars]" sections applied to
+// every host in the matching group.
+func LoadInventory(r io.Reader) ([]Dest, error) {
+	groupHosts := map[string][]string{}
+	hostVars := map[string]map[string]string{}
+	groupVars := map[string]map[string]string{}
+	var order []string
+
+	group := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		alias := fields[0]
+		vars := parseInlineVars(fields[1:])
+
+		if strings.HasSuffix(group, ":vars") {
+			// A ":vars" line is "key=value", not "host key=value...".
+			base := strings.TrimSuffix(group, ":vars")
+			if groupVars[base] == nil {
+				groupVars[base] = map[string]string{}
+			}
+			for _, f := range fields {
+				if k, v, ok := strings.Cut(f, "="); ok {
+					groupVars[base][k] = v
+				}
+			}
+			continue
+		}
+
+		if _, exists := hostVars[alias]; !exists {
+			order = append(order, alias)
+		}
+		if hostVars[alias] == nil {
+			hostVars[alias] = map[string]string{}
+		}
+		for k, v := range vars {
+			hostVars[alias][k] = v
+		}
+		groupHosts[group] = append(groupHosts[group], alias)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	aliasGroups := map[string][]string{}
+	for g, hosts := range groupHosts {
+		if g == "" {
+			continue
+		}
+		for _, h := range hosts {
+			aliasGroups[h] = append(aliasGroups[h], g)
+		}
+	}
+
+	dests := make([]Dest, 0, len(order))
+	for _, alias := range order {
+		merged := map[string]string{}
+		for _, g := range aliasGroups[alias] {
+			for k, v := range groupVars[g] {
+				merged[k] = v
+			}
+		}
+		for k, v := range hostVars[alias] {
+			merged[k] = v
+		}
+
+		dest := Dest{Host: alias, Groups: aliasGroups[alias]}
+		if v, ok := merged["ansible_host"]; ok {
+			dest.Host = v
+		}
+		dest.User = merged["ansible_user"]
+		if v, ok := merged["ansible_port"]; ok {
+			if _, err := strconv.Atoi(v); err == nil {
+				dest.Port = v
+			}
+		}
+		dest.IdentityFile = merged["ansible_ssh_private_key_file"]
+
+		dests = append(dests, dest)
+	}
+
+	return dests, nil
+}
+
+func parseInlineVars(fields []string) map[string]string {
+	vars := map[string]string{}
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if ok {
+			vars[k] = v
+		}
+	}
+	return vars
+}
+
+// FilterGroup keeps only the dests that belong to group.
+func FilterGroup(dests []Dest, group string) []Dest {
+	if group == "" {
+		return dests
+	}
+
+	var filtered []Dest
+	for _, d := range dests {
+		for _, g := range d.Groups {
+			if g == group {
+				filtered = append(filtered, d)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,110 @@
+package inventory
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadSSHConfig(t *testing.T) {
+	config := `
+Host web1
+  HostName 10.0.0.1
+  Port 2222
+
+Host web2
+  HostName 10.0.0.2
+  IdentityFile ~/.ssh/web2_key
+
+# defaults for everything behind the vpn, applied after the literal
+# blocks above since ssh_config precedence is first-match-wins
+Host *.internal
+  User deploy
+  ProxyJump bastion
+
+Host db1.internal
+  IdentityFile ~/.ssh/db_key
+`
+
+	dests, err := LoadSSHConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("LoadSSHConfig: %v", err)
+	}
+
+	byHost := map[string]Dest{}
+	for _, d := range dests {
+		byHost[d.Host] = d
+	}
+
+	want := map[string]Dest{
+		"10.0.0.1": {Host: "10.0.0.1", Port: "2222"},
+		"10.0.0.2": {Host: "10.0.0.2", IdentityFile: "~/.ssh/web2_key"},
+	}
+	if got, want := byHost["10.0.0.1"], want["10.0.0.1"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("web1 = %#v, want %#v", got, want)
+	}
+	if got, want := byHost["10.0.0.2"], want["10.0.0.2"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("web2 = %#v, want %#v", got, want)
+	}
+
+	db1, ok := byHost["db1.internal"]
+	if !ok {
+		t.Fatalf("db1.internal not found in %#v", byHost)
+	}
+	if db1.User != "deploy" || db1.Jump != "bastion" || db1.IdentityFile != "~/.ssh/db_key" {
+		t.Fatalf("db1.internal = %#v, want User=deploy Jump=bastion IdentityFile=~/.ssh/db_key", db1)
+	}
+}
+
+func TestLoadInventory(t *testing.T) {
+	ini := `
+[web]
+web1 ansible_host=10.0.0.1 ansible_port=2222
+web2 ansible_host=10.0.0.2
+
+[web:vars]
+ansible_user=deploy
+
+[db]
+db1 ansible_host=10.0.0.3 ansible_user=postgres
+`
+
+	dests, err := LoadInventory(strings.NewReader(ini))
+	if err != nil {
+		t.Fatalf("LoadInventory: %v", err)
+	}
+
+	byAlias := map[string]Dest{}
+	for _, d := range dests {
+		if len(d.Groups) == 0 {
+			t.Fatalf("dest %#v has no groups", d)
+		}
+		// normalize for comparison
+		groups := append([]string{}, d.Groups...)
+		sort.Strings(groups)
+		d.Groups = groups
+		byAlias[d.Host] = d
+	}
+
+	web1, ok := byAlias["10.0.0.1"]
+	if !ok {
+		t.Fatalf("expected web1 (host 10.0.0.1), got %#v", byAlias)
+	}
+	if web1.User != "deploy" || web1.Port != "2222" {
+		t.Fatalf("web1 = %#v, want User=deploy Port=2222", web1)
+	}
+
+	db1, ok := byAlias["10.0.0.3"]
+	if !ok {
+		t.Fatalf("expected db1 (host 10.0.0.3), got %#v", byAlias)
+	}
+	if db1.User != "postgres" {
+		t.Fatalf("db1 = %#v, want User=postgres", db1)
+	}
+
+	filtered := FilterGroup(dests, "db")
+	if len(filtered) != 1 || filtered[0].Host != "10.0.0.3" {
+		t.Fatalf("FilterGroup(db) = %#v, want just 10.0.0.3", filtered)
+	}
+}
@@ -1,26 +1,49 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
-	"golang.org/x/crypto/ssh"
+
+	"github.com/himetani/cron-job-list/inventory"
 )
 
 var help bool
 var quiet bool
 var privateKey string
 var port string
+var useAgent bool
+var knownHostsFile string
+var insecure bool
+var outputFormat string
+var nextRuns int
+var concurrency int
+var timeout time.Duration
+var retries int
+var retryBackoff time.Duration
+var bastion string
+var sshConfigPath string
+var inventoryPath string
+var group string
 
 const (
-	defaultPort = "22"
+	defaultPort         = "22"
+	defaultConcurrency  = 10
+	defaultTimeout      = 30 * time.Second
+	defaultRetryBackoff = 2 * time.Second
+
+	exitSuccess      = 0
+	exitTotalFailure = 1
+	exitPartial      = 2
 )
 
 func init() {
@@ -28,35 +51,37 @@ func init() {
 	flag.BoolVar(&quiet, "q", false, "Don't show the INFO log")
 	flag.StringVar(&privateKey, "i", "", "Private key")
 	flag.StringVar(&port, "p", "", "Port")
-	flag.Parse()
+	flag.BoolVar(&useAgent, "A", false, "Authenticate via ssh-agent (SSH_AUTH_SOCK)")
+	flag.BoolVar(&useAgent, "agent", false, "Authenticate via ssh-agent (SSH_AUTH_SOCK)")
+	flag.StringVar(&knownHostsFile, "known-hosts", "", "known_hosts file used to verify host keys (default ~/.ssh/known_hosts)")
+	flag.BoolVar(&insecure, "insecure", false, "Skip host key verification (insecure, must be requested explicitly)")
+	flag.StringVar(&outputFormat, "o", "raw", "Output format: json, yaml, table, or raw")
+	flag.IntVar(&nextRuns, "n", 0, "Include the next N scheduled run times for each entry")
+	flag.IntVar(&concurrency, "concurrency", defaultConcurrency, "Maximum number of hosts to query at once")
+	flag.DurationVar(&timeout, "timeout", defaultTimeout, "Per-host dial and command timeout (0 disables it)")
+	flag.IntVar(&retries, "retries", 0, "Number of times to retry a host after a dial failure")
+	flag.DurationVar(&retryBackoff, "retry-backoff", defaultRetryBackoff, "Delay between retries")
+	flag.StringVar(&bastion, "bastion", "", "Default jump host chain (\"user@host:port,...\") for destinations without their own \"jump\"")
+	flag.StringVar(&sshConfigPath, "ssh-config", "", "Discover destinations from an OpenSSH-style config file instead of a JSON configfile")
+	flag.StringVar(&inventoryPath, "inventory", "", "Discover destinations from an Ansible-style INI inventory file instead of a JSON configfile")
+	flag.StringVar(&group, "G", "", "Restrict -inventory destinations to this group")
 }
 
 func main() {
+	flag.Parse()
+
 	if help {
 		showUsage()
 		os.Exit(0)
 	}
 
 	args := flag.Args()
-	if len(args) != 1 {
-		fmt.Fprintf(os.Stderr, "ERROR: Arguments length is invalid\n")
-		fmt.Fprintf(os.Stderr, "Usage: %s [configfile]\n", os.Args[0])
-		os.Exit(1)
-	}
-
-	file, err := os.Open(args[0])
+	dests, err := loadDests(args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 		os.Exit(1)
 	}
 
-	var dests []Dest
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&dests); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-		os.Exit(1)
-	}
-
 	if privateKey == "" {
 		home, err := homedir.Dir()
 		if err != nil {
@@ -70,106 +95,271 @@ func main() {
 		port = defaultPort
 	}
 
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	opts := AuthOptions{
+		UseAgent:       useAgent,
+		PrivateKey:     privateKey,
+		KnownHostsFile: knownHostsFile,
+		Insecure:       insecure,
+	}
+
+	reporter, err := reporterFor(outputFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	results := runAll(dests, opts)
+
+	if err := reporter.Report(os.Stdout, results); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	os.Exit(exitCode(results))
+}
+
+// runAll queries every destination through a pool of at most
+// `concurrency` workers and returns one HostResult per destination, in
+// the same order as dests.
+func runAll(dests []Dest, opts AuthOptions) []HostResult {
+	results := make([]HostResult, len(dests))
+	sem := make(chan struct{}, concurrency)
+
 	var wg sync.WaitGroup
 	wg.Add(len(dests))
-	for _, dest := range dests {
-		go call(&wg, dest.Host, dest.User)
+	for i, dest := range dests {
+		sem <- struct{}{}
+		go func(i int, dest Dest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = call(dest, opts, nextRuns)
+		}(i, dest)
 	}
 	wg.Wait()
+
+	return results
+}
+
+// exitCode follows the convention expected by CI/monitoring callers:
+// 0 when every host succeeded, 1 when every host failed, 2 otherwise.
+func exitCode(results []HostResult) int {
+	failures := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failures++
+		}
+	}
+
+	switch {
+	case failures == 0:
+		return exitSuccess
+	case failures == len(results):
+		return exitTotalFailure
+	default:
+		return exitPartial
+	}
 }
 
-func call(wg *sync.WaitGroup, host, user string) {
-	defer wg.Done()
-	session, err := NewSession(host, port, user, privateKey)
+func call(dest Dest, opts AuthOptions, nextRuns int) HostResult {
+	result := HostResult{Host: dest.Host, User: dest.User}
+
+	session, err := dialWithRetry(dest, opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: [Host] %s@%s\n", host, user)
+		result.Error = err.Error()
+		return result
 	}
 	defer session.Close()
 
-	bytes, err := session.GetCrontab()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: [Host] %s@%s\n", host, user)
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
+	raw, err := session.GetCrontab(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: [Host] %s@%s\n", host, user)
-		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Raw = string(raw)
+	result.Entries = ParseCrontab(raw)
+
+	if nextRuns > 0 {
+		loc := resolveLocation(dest.TZ, raw)
+		now := time.Now()
+		for i := range result.Entries {
+			result.Entries[i].NextRuns = NextRunN(result.Entries[i], nextRuns, now, loc)
+		}
 	}
 
-	content := fmt.Sprintf(string(bytes))
-	fmt.Printf("[Host] %s@%s\n", user, host)
-	fmt.Printf("[Content] \n%s\n", content)
+	return result
 }
 
-func showUsage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [configfile]\n", os.Args[0])
-	fmt.Fprintf(os.Stderr, "Flags:\n")
-	flag.PrintDefaults()
+// dialWithRetry calls NewSession, retrying up to `retries` times with
+// `retryBackoff` between attempts when the failure looks like a
+// transient dial/handshake problem (refused, unreachable, timed out).
+// Permanent failures, such as bad auth or an untrusted host key, are
+// returned immediately since retrying them would just fail the same
+// way again.
+func dialWithRetry(dest Dest, opts AuthOptions) (*Session, error) {
+	if dest.Jump == "" {
+		dest.Jump = bastion
+	}
+	if dest.IdentityFile != "" {
+		opts.PrivateKey = dest.IdentityFile
+	}
+	if dest.KnownHostsFile != "" {
+		opts.KnownHostsFile = dest.KnownHostsFile
+	}
+
+	var session *Session
+	var err error
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		session, err = NewSession(dest, port, opts, timeout)
+		if err == nil {
+			return session, nil
+		}
+		if !isRetryableDialErr(err) {
+			return nil, err
+		}
+		if attempt < retries {
+			time.Sleep(retryBackoff)
+		}
+	}
+
+	return nil, err
 }
 
-type Dest struct {
-	Host string `json:"host"`
-	User string `json:"user"`
+// isRetryableDialErr reports whether err looks like a transient
+// network-level failure (dial refused/unreachable, handshake timeout)
+// worth retrying, as opposed to a permanent failure like a rejected
+// auth method or an untrusted host key.
+func isRetryableDialErr(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
-// Session is struct representing ssh Session
-type Session struct {
-	config    *ssh.ClientConfig
-	conn      *ssh.Client
-	session   *ssh.Session
-	StdinPipe io.WriteCloser
+// loadDests resolves the []Dest to query: from the positional JSON
+// configfile by default, or from --ssh-config / --inventory (which
+// take no positional argument) when either is set.
+func loadDests(args []string) ([]Dest, error) {
+	if sshConfigPath == "" && inventoryPath == "" {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("ERROR: Arguments length is invalid\nUsage: %s [configfile]", os.Args[0])
+		}
+		return loadDestsFromJSON(args[0])
+	}
+
+	if len(args) != 0 {
+		return nil, fmt.Errorf("a [configfile] argument cannot be combined with -ssh-config or -inventory")
+	}
+
+	var dests []Dest
+
+	if sshConfigPath != "" {
+		found, err := loadDestsFromSSHConfig(sshConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		dests = append(dests, found...)
+	}
+
+	if inventoryPath != "" {
+		found, err := loadDestsFromInventory(inventoryPath, group)
+		if err != nil {
+			return nil, err
+		}
+		dests = append(dests, found...)
+	}
+
+	return dests, nil
 }
 
-// NewSession returns new Session instance
-func NewSession(ip, port, user, privateKey string) (*Session, error) {
-	buf, err := ioutil.ReadFile(privateKey)
+func loadDestsFromJSON(path string) ([]Dest, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	key, err := ssh.ParsePrivateKey(buf)
-	if err != nil {
+	var dests []Dest
+	if err := json.NewDecoder(file).Decode(&dests); err != nil {
 		return nil, err
 	}
+	return dests, nil
+}
 
-	config := &ssh.ClientConfig{
-		User:            user,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(key),
-		},
+func loadDestsFromSSHConfig(path string) ([]Dest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	conn, err := ssh.Dial("tcp", ip+":"+port, config)
+	found, err := inventory.LoadSSHConfig(file)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("parse ssh config %s: %w", path, err)
 	}
+	return convertInventoryDests(found), nil
+}
 
-	session, err := conn.NewSession()
+func loadDestsFromInventory(path, group string) ([]Dest, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	return &Session{
-		config:  config,
-		conn:    conn,
-		session: session,
-	}, nil
+	found, err := inventory.LoadInventory(file)
+	if err != nil {
+		return nil, fmt.Errorf("parse inventory %s: %w", path, err)
+	}
+	return convertInventoryDests(inventory.FilterGroup(found, group)), nil
 }
 
-// Close close the session & connection
-func (s *Session) Close() {
-	if s.session != nil {
-		s.session.Close()
+func convertInventoryDests(found []inventory.Dest) []Dest {
+	dests := make([]Dest, len(found))
+	for i, d := range found {
+		dests[i] = Dest{
+			Host:           d.Host,
+			User:           d.User,
+			Port:           d.Port,
+			IdentityFile:   d.IdentityFile,
+			Jump:           d.Jump,
+			KnownHostsFile: d.KnownHostsFile,
+		}
 	}
+	return dests
+}
 
-	if s.conn != nil {
-		s.conn.Close()
-	}
+func showUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [configfile]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
 }
 
-// Get is func that get file contents
-func (s *Session) GetCrontab() ([]byte, error) {
-	cmd := fmt.Sprintf("crontab -l\n")
-	return s.session.Output(cmd)
+type Dest struct {
+	Host string `json:"host"`
+	User string `json:"user"`
+	// Port overrides the global -p port for this host.
+	Port string `json:"port,omitempty"`
+	// TZ overrides the timezone used to compute next-run times for
+	// this host; if empty, a TZ= line in the crontab is used, falling
+	// back to the local timezone.
+	TZ string `json:"tz,omitempty"`
+	// Jump is a comma-separated "user@host:port" ProxyJump-style
+	// chain of bastions to dial through before reaching Host. If
+	// empty, the global --bastion default is used instead.
+	Jump string `json:"jump,omitempty"`
+	// IdentityFile overrides the global -i private key for this host.
+	IdentityFile string `json:"identity_file,omitempty"`
+	// KnownHostsFile overrides the global --known-hosts file for this host.
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		name    string
+		results []HostResult
+		want    int
+	}{
+		{"all success", []HostResult{{}, {}}, exitSuccess},
+		{"all failure", []HostResult{{Error: "boom"}, {Error: "boom"}}, exitTotalFailure},
+		{"partial failure", []HostResult{{}, {Error: "boom"}}, exitPartial},
+		{"no hosts", nil, exitSuccess},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := exitCode(c.results); got != c.want {
+				t.Fatalf("exitCode() = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoadDests_JSONConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dests.json")
+	if err := os.WriteFile(path, []byte(`[{"host":"h1","user":"u1"}]`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	dests, err := loadDests([]string{path})
+	if err != nil {
+		t.Fatalf("loadDests: %v", err)
+	}
+	if len(dests) != 1 || dests[0].Host != "h1" || dests[0].User != "u1" {
+		t.Fatalf("loadDests() = %#v, want [{Host:h1 User:u1}]", dests)
+	}
+}
+
+func TestLoadDests_RejectsConfigFileWithInventoryFlag(t *testing.T) {
+	inventoryPath = "some-inventory.ini"
+	defer func() { inventoryPath = "" }()
+
+	if _, err := loadDests([]string{"dests.json"}); err == nil {
+		t.Fatal("expected an error combining a configfile with -inventory, got nil")
+	}
+}
+
+// startThrottledTestServer is like startTestServer, except every exec
+// request holds the connection open for a short while and tracks how
+// many requests across all instances are in flight at once, so
+// TestRunAll_BoundedConcurrency can observe runAll's worker pool.
+func startThrottledTestServer(t *testing.T, wantKey ssh.PublicKey, current, peak *int32) *testServer {
+	t.Helper()
+
+	hostSigner, err := ssh.NewSignerFromKey(generateTestKey(t))
+	if err != nil {
+		t.Fatalf("host key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), wantKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(nConn net.Conn) {
+				conn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				go ssh.DiscardRequests(reqs)
+
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range requests {
+							if req.Type != "exec" {
+								req.Reply(false, nil)
+								continue
+							}
+
+							n := atomic.AddInt32(current, 1)
+							for {
+								p := atomic.LoadInt32(peak)
+								if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+									break
+								}
+							}
+							time.Sleep(50 * time.Millisecond)
+							atomic.AddInt32(current, -1)
+
+							channel.Write([]byte("* * * * * /bin/true\n"))
+							req.Reply(true, nil)
+							channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+							channel.Close()
+						}
+					}()
+				}
+			}(nConn)
+		}
+	}()
+
+	return &testServer{addr: listener.Addr().String(), hostKey: hostSigner}
+}
+
+func TestRunAll_BoundedConcurrency(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+	keyPath := writePEMKey(t, clientKey)
+
+	const hostCount = 6
+	const maxConcurrency = 2
+
+	var current, peak int32
+	dests := make([]Dest, hostCount)
+	for i := 0; i < hostCount; i++ {
+		srv := startThrottledTestServer(t, clientSigner.PublicKey(), &current, &peak)
+		host, port := dialHostPort(srv.addr)
+		dests[i] = Dest{Host: host, User: "test", Port: port}
+	}
+
+	oldConcurrency, oldTimeout, oldRetries := concurrency, timeout, retries
+	concurrency, timeout, retries = maxConcurrency, 0, 0
+	defer func() { concurrency, timeout, retries = oldConcurrency, oldTimeout, oldRetries }()
+
+	results := runAll(dests, AuthOptions{PrivateKey: keyPath, Insecure: true})
+
+	if len(results) != hostCount {
+		t.Fatalf("runAll() returned %d results, want %d", len(results), hostCount)
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			t.Fatalf("unexpected host error: %s", r.Error)
+		}
+	}
+
+	if got := atomic.LoadInt32(&peak); got > maxConcurrency {
+		t.Fatalf("peak concurrent requests = %d, want <= %d (concurrency limit)", got, maxConcurrency)
+	}
+	if got := atomic.LoadInt32(&peak); got < 2 {
+		t.Fatalf("peak concurrent requests = %d, want workers to actually overlap", got)
+	}
+}
+
+func TestDialWithRetry_RetriesThenFails(t *testing.T) {
+	// Nothing listens on this port, so every dial attempt fails
+	// immediately with "connection refused" — a transient, retryable
+	// error. Supply a real private key so the failure comes from the
+	// dial itself, not from authMethods running out of auth options.
+	clientKey := generateTestKey(t)
+	keyPath := writePEMKey(t, clientKey)
+	dest := Dest{Host: "127.0.0.1", Port: "1", User: "test"}
+
+	oldRetries, oldBackoff, oldTimeout := retries, retryBackoff, timeout
+	retries, retryBackoff, timeout = 2, 20*time.Millisecond, time.Second
+	defer func() { retries, retryBackoff, timeout = oldRetries, oldBackoff, oldTimeout }()
+
+	start := time.Now()
+	if _, err := dialWithRetry(dest, AuthOptions{PrivateKey: keyPath, Insecure: true}); err == nil {
+		t.Fatal("expected an error dialing a closed port, got nil")
+	}
+
+	if elapsed := time.Since(start); elapsed < 2*retryBackoff {
+		t.Fatalf("dialWithRetry returned after %v, want at least %v for %d retries at %v backoff", elapsed, 2*retryBackoff, retries, retryBackoff)
+	}
+}
+
+func TestDialWithRetry_FailsFastOnAuthError(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	// The server only accepts clientSigner's key; dialing with a
+	// different key is a permanent auth failure, not a transient one.
+	srv := startTestServer(t, clientSigner.PublicKey())
+	wrongKeyPath := writePEMKey(t, generateTestKey(t))
+
+	host, port := dialHostPort(srv.addr)
+	dest := Dest{Host: host, User: "test", Port: port}
+
+	oldRetries, oldBackoff, oldTimeout := retries, retryBackoff, timeout
+	retries, retryBackoff, timeout = 5, time.Second, time.Second
+	defer func() { retries, retryBackoff, timeout = oldRetries, oldBackoff, oldTimeout }()
+
+	start := time.Now()
+	if _, err := dialWithRetry(dest, AuthOptions{PrivateKey: wrongKeyPath, Insecure: true}); err == nil {
+		t.Fatal("expected an auth error, got nil")
+	}
+
+	if elapsed := time.Since(start); elapsed >= retryBackoff {
+		t.Fatalf("dialWithRetry took %v, want it to fail fast without sleeping through a %v backoff (auth errors aren't retryable)", elapsed, retryBackoff)
+	}
+}
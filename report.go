@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HostResult is the outcome of fetching and parsing one host's
+// crontab, ready to be handed to a Reporter.
+type HostResult struct {
+	Host    string      `json:"host" yaml:"host"`
+	User    string      `json:"user" yaml:"user"`
+	Entries []CronEntry `json:"entries" yaml:"entries"`
+	Raw     string      `json:"-" yaml:"-"`
+	Error   string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Reporter renders a batch of HostResult values to w.
+type Reporter interface {
+	Report(w io.Writer, results []HostResult) error
+}
+
+// reporterFor resolves the -o flag value to a Reporter, defaulting to
+// the original "raw" text format when format is empty.
+func reporterFor(format string) (Reporter, error) {
+	switch format {
+	case "", "raw":
+		return rawReporter{}, nil
+	case "json":
+		return jsonReporter{}, nil
+	case "yaml":
+		return yamlReporter{}, nil
+	case "table":
+		return tableReporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want json, yaml, table, or raw)", format)
+	}
+}
+
+type rawReporter struct{}
+
+func (rawReporter) Report(w io.Writer, results []HostResult) error {
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(w, "ERROR: [Host] %s@%s\n%s\n", r.User, r.Host, r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "[Host] %s@%s\n", r.User, r.Host)
+		fmt.Fprintf(w, "[Content] \n%s\n", r.Raw)
+	}
+	return nil
+}
+
+type jsonReporter struct{}
+
+func (jsonReporter) Report(w io.Writer, results []HostResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+type yamlReporter struct{}
+
+func (yamlReporter) Report(w io.Writer, results []HostResult) error {
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+	return enc.Encode(results)
+}
+
+type tableReporter struct{}
+
+func (tableReporter) Report(w io.Writer, results []HostResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tUSER\tMINUTE\tHOUR\tDOM\tMONTH\tDOW\tCOMMAND\tCOMMENT\tNEXT_RUN")
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\tERROR\t%s\t\t\t\t\t\t\n", r.Host, r.User, r.Error)
+			continue
+		}
+		for _, e := range r.Entries {
+			var nextRun string
+			if len(e.NextRuns) > 0 {
+				nextRun = e.NextRuns[0].Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				r.Host, r.User, e.Minute, e.Hour, e.Dom, e.Month, e.Dow, e.Command, e.Comment, nextRun)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScanMonths bounds how far into the future NextRun will look before
+// giving up on a spec that can never match (e.g. "31" as day-of-month
+// for a month that never has 31 days is fine since other months do,
+// but a genuinely impossible combination should not loop forever).
+const maxScanMonths = 60
+
+// NextRun returns the next time entry fires strictly after from, in
+// loc. It returns the zero Time if entry has no time-based schedule
+// (e.g. "@reboot") or if no match is found within maxScanMonths.
+func NextRun(entry CronEntry, from time.Time, loc *time.Location) time.Time {
+	if entry.Minute == "@reboot" {
+		return time.Time{}
+	}
+
+	minutes := expandField(entry.Minute, 0, 59)
+	hours := expandField(entry.Hour, 0, 23)
+	doms := expandField(entry.Dom, 1, 31)
+	months := expandField(entry.Month, 1, 12)
+	dows := expandDow(entry.Dow)
+
+	if len(minutes) == 0 || len(hours) == 0 || len(doms) == 0 || len(months) == 0 || len(dows) == 0 {
+		return time.Time{}
+	}
+
+	domRestricted := strings.TrimSpace(entry.Dom) != "*"
+	dowRestricted := strings.TrimSpace(entry.Dow) != "*"
+
+	t := from.In(loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(0, maxScanMonths, 0)
+
+	for t.Before(limit) {
+		if !containsInt(months, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc).AddDate(0, 1, 0)
+			continue
+		}
+
+		if !domMatches(t, doms, dows, domRestricted, dowRestricted) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+			continue
+		}
+
+		if match, ht := nextHourMinute(t, hours, minutes); match {
+			return ht
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	}
+
+	return time.Time{}
+}
+
+// NextRunN returns up to n successive fire times for entry after from.
+func NextRunN(entry CronEntry, n int, from time.Time, loc *time.Location) []time.Time {
+	var runs []time.Time
+	cursor := from
+	for i := 0; i < n; i++ {
+		next := NextRun(entry, cursor, loc)
+		if next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+		cursor = next
+	}
+	return runs
+}
+
+// domMatches applies the standard Vixie-cron rule: when both
+// day-of-month and day-of-week are restricted, a day matches if
+// *either* set matches (union); otherwise the single restricted set
+// (or "always" if neither is restricted) decides.
+func domMatches(t time.Time, doms, dows []int, domRestricted, dowRestricted bool) bool {
+	domMatch := containsInt(doms, t.Day())
+	dowMatch := containsInt(dows, int(t.Weekday()))
+
+	switch {
+	case domRestricted && dowRestricted:
+		return domMatch || dowMatch
+	case domRestricted:
+		return domMatch
+	case dowRestricted:
+		return dowMatch
+	default:
+		return true
+	}
+}
+
+// nextHourMinute finds the earliest hour/minute combination, at or
+// after t's current hour/minute, that is present in hours and minutes.
+func nextHourMinute(t time.Time, hours, minutes []int) (bool, time.Time) {
+	for _, h := range hours {
+		if h < t.Hour() {
+			continue
+		}
+
+		minMinute := minutes[0]
+		if h == t.Hour() {
+			found := false
+			for _, m := range minutes {
+				if m >= t.Minute() {
+					minMinute = m
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		return true, time.Date(t.Year(), t.Month(), t.Day(), h, minMinute, 0, 0, t.Location())
+	}
+	return false, time.Time{}
+}
+
+// expandField normalizes a single cron field (minute, hour, dom, or
+// month) into a sorted set of distinct ints within [min, max],
+// expanding "*", "a-b", "a,b,c", and "*/n" (and combinations such as
+// "a-b/n").
+func expandField(spec string, min, max int) []int {
+	set := map[int]bool{}
+
+	for _, part := range strings.Split(strings.TrimSpace(spec), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		base := part
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			base = part[:idx]
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				continue
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case base == "*":
+			// lo, hi already the full range
+		case strings.Contains(base, "-"):
+			bounds := strings.SplitN(base, "-", 2)
+			a, errA := strconv.Atoi(bounds[0])
+			b, errB := strconv.Atoi(bounds[1])
+			if errA != nil || errB != nil {
+				continue
+			}
+			lo, hi = a, b
+		default:
+			v, err := strconv.Atoi(base)
+			if err != nil {
+				continue
+			}
+			lo, hi = v, v
+		}
+
+		for v := lo; v <= hi; v += step {
+			if v >= min && v <= max {
+				set[v] = true
+			}
+		}
+	}
+
+	result := make([]int, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// expandDow is expandField for day-of-week, additionally folding the
+// alias "7" (Sunday, per POSIX/Vixie cron) onto "0" (time.Sunday).
+func expandDow(spec string) []int {
+	raw := expandField(spec, 0, 7)
+	set := map[int]bool{}
+	for _, v := range raw {
+		if v == 7 {
+			v = 0
+		}
+		set[v] = true
+	}
+	result := make([]int, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// resolveLocation picks the timezone to use for a host's next-run
+// times: an explicit override, then a TZ= line in its crontab, then
+// the local timezone.
+func resolveLocation(override string, raw []byte) *time.Location {
+	name := override
+	if name == "" {
+		name = detectTZ(raw)
+	}
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+func containsInt(set []int, v int) bool {
+	for _, s := range set {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun_EveryMinute(t *testing.T) {
+	entry := CronEntry{Minute: "*", Hour: "*", Dom: "*", Month: "*", Dow: "*"}
+	from := time.Date(2026, 7, 27, 10, 30, 0, 0, time.UTC)
+
+	got := NextRun(entry, from, time.UTC)
+	want := time.Date(2026, 7, 27, 10, 31, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("NextRun() = %v, want %v", got, want)
+	}
+}
+
+func TestNextRun_SpecificHourMinute(t *testing.T) {
+	entry := CronEntry{Minute: "30", Hour: "2", Dom: "*", Month: "*", Dow: "*"}
+	from := time.Date(2026, 7, 27, 10, 0, 0, 0, time.UTC)
+
+	got := NextRun(entry, from, time.UTC)
+	want := time.Date(2026, 7, 28, 2, 30, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("NextRun() = %v, want %v", got, want)
+	}
+}
+
+func TestNextRun_StepAndRange(t *testing.T) {
+	entry := CronEntry{Minute: "*/15", Hour: "9-17", Dom: "*", Month: "*", Dow: "1-5"}
+	// Monday 2026-07-27 08:50 UTC
+	from := time.Date(2026, 7, 27, 8, 50, 0, 0, time.UTC)
+
+	got := NextRun(entry, from, time.UTC)
+	want := time.Date(2026, 7, 27, 9, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("NextRun() = %v, want %v", got, want)
+	}
+}
+
+func TestNextRun_DomDowUnion(t *testing.T) {
+	// Fires on the 1st of the month OR on Fridays (union, Vixie-cron rule).
+	entry := CronEntry{Minute: "0", Hour: "0", Dom: "1", Month: "*", Dow: "5"}
+	// 2026-07-27 is a Monday; the next Friday is 2026-07-31, which is
+	// also the day before the 1st, so the 1st (2026-08-01, a Saturday)
+	// should win as the earlier of the two matches.
+	from := time.Date(2026, 7, 27, 0, 0, 0, 0, time.UTC)
+
+	got := NextRun(entry, from, time.UTC)
+	want := time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("NextRun() = %v, want %v", got, want)
+	}
+}
+
+func TestNextRun_Reboot(t *testing.T) {
+	entry := CronEntry{Minute: "@reboot", Command: "/usr/bin/startup.sh"}
+	got := NextRun(entry, time.Now(), time.UTC)
+	if !got.IsZero() {
+		t.Fatalf("NextRun() for @reboot = %v, want zero time", got)
+	}
+}
+
+func TestNextRunN(t *testing.T) {
+	entry := CronEntry{Minute: "0", Hour: "*", Dom: "*", Month: "*", Dow: "*"}
+	from := time.Date(2026, 7, 27, 10, 30, 0, 0, time.UTC)
+
+	runs := NextRunN(entry, 3, from, time.UTC)
+	want := []time.Time{
+		time.Date(2026, 7, 27, 11, 0, 0, 0, time.UTC),
+		time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 7, 27, 13, 0, 0, 0, time.UTC),
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("NextRunN() returned %d runs, want %d", len(runs), len(want))
+	}
+	for i := range want {
+		if !runs[i].Equal(want[i]) {
+			t.Fatalf("NextRunN()[%d] = %v, want %v", i, runs[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// AuthOptions controls how NewSession authenticates and verifies host keys.
+type AuthOptions struct {
+	// UseAgent tries SSH_AUTH_SOCK before falling back to PrivateKey.
+	UseAgent bool
+	// PrivateKey is the private key file used when agent auth is
+	// disabled, unavailable, or rejected by the server.
+	PrivateKey string
+	// KnownHostsFile is consulted to verify host keys. Defaults to
+	// ~/.ssh/known_hosts when empty.
+	KnownHostsFile string
+	// Insecure disables host key verification entirely.
+	Insecure bool
+}
+
+// Session is struct representing ssh Session
+type Session struct {
+	config      *ssh.ClientConfig
+	conn        *ssh.Client
+	jumpClients []*ssh.Client
+	session     *ssh.Session
+	StdinPipe   io.WriteCloser
+}
+
+// NewSession returns a new Session for dest, dialing through dest's
+// jump chain (if any) first. defaultPort is used when dest (or a hop
+// in its jump chain) doesn't specify its own port. dialTimeout bounds
+// each TCP dial and SSH handshake along the way; zero means no
+// timeout.
+func NewSession(dest Dest, defaultPort string, opts AuthOptions, dialTimeout time.Duration) (*Session, error) {
+	auth, err := authMethods(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := hostKeyCallback(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var jumpClients []*ssh.Client
+	dial := func(network, addr string) (net.Conn, error) {
+		return net.DialTimeout(network, addr, dialTimeout)
+	}
+
+	for _, hop := range parseJumpChain(dest.Jump, dest.User, defaultPort) {
+		hopConfig := &ssh.ClientConfig{
+			User:            hop.user,
+			HostKeyCallback: hostKeyCallback,
+			Auth:            auth,
+		}
+
+		addr := net.JoinHostPort(hop.host, hop.port)
+		netConn, err := dial("tcp", addr)
+		if err != nil {
+			closeClients(jumpClients)
+			return nil, fmt.Errorf("jump host %s: %w", addr, err)
+		}
+
+		sshConn, chans, reqs, err := newClientConn(netConn, addr, hopConfig, dialTimeout)
+		if err != nil {
+			netConn.Close()
+			closeClients(jumpClients)
+			return nil, fmt.Errorf("jump host %s: %w", addr, err)
+		}
+
+		client := ssh.NewClient(sshConn, chans, reqs)
+		jumpClients = append(jumpClients, client)
+		dial = client.Dial
+	}
+
+	config := &ssh.ClientConfig{
+		User:            dest.User,
+		HostKeyCallback: hostKeyCallback,
+		Auth:            auth,
+	}
+
+	port := dest.Port
+	if port == "" {
+		port = defaultPort
+	}
+	addr := net.JoinHostPort(dest.Host, port)
+
+	netConn, err := dial("tcp", addr)
+	if err != nil {
+		closeClients(jumpClients)
+		return nil, err
+	}
+
+	sshConn, chans, reqs, err := newClientConn(netConn, addr, config, dialTimeout)
+	if err != nil {
+		netConn.Close()
+		closeClients(jumpClients)
+		return nil, err
+	}
+	conn := ssh.NewClient(sshConn, chans, reqs)
+
+	session, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		closeClients(jumpClients)
+		return nil, err
+	}
+
+	return &Session{
+		config:      config,
+		conn:        conn,
+		jumpClients: jumpClients,
+		session:     session,
+	}, nil
+}
+
+// newClientConn wraps ssh.NewClientConn with a deadline on netConn so a
+// server that accepts the TCP connection but stalls during key
+// exchange or authentication doesn't hang the handshake forever. The
+// deadline is cleared before returning so it doesn't affect the
+// session traffic that follows.
+func newClientConn(netConn net.Conn, addr string, config *ssh.ClientConfig, timeout time.Duration) (ssh.Conn, <-chan ssh.NewChannel, <-chan *ssh.Request, error) {
+	if timeout > 0 {
+		netConn.SetDeadline(time.Now().Add(timeout))
+		defer netConn.SetDeadline(time.Time{})
+	}
+	return ssh.NewClientConn(netConn, addr, config)
+}
+
+func closeClients(clients []*ssh.Client) {
+	for _, c := range clients {
+		c.Close()
+	}
+}
+
+// authMethods builds the AuthMethod chain: ssh-agent first (when enabled
+// and SSH_AUTH_SOCK is reachable), then the private key file as a
+// fallback so a single unlocked key is no longer required to reach a
+// whole fleet. A stale or unreachable SSH_AUTH_SOCK is treated the same
+// as "agent unavailable" rather than aborting the whole chain, since a
+// dead agent is at least as common as no agent at all.
+func authMethods(opts AuthOptions) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if opts.UseAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.Dial("unix", sock); err == nil {
+				agentClient := agent.NewClient(conn)
+				methods = append(methods, ssh.PublicKeysCallback(agentClient.Signers))
+			}
+		}
+	}
+
+	if opts.PrivateKey != "" {
+		path, err := homedir.Expand(opts.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := ssh.ParsePrivateKey(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		methods = append(methods, ssh.PublicKeys(key))
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no authentication method available: enable -A or set a private key with -i")
+	}
+
+	return methods, nil
+}
+
+// hostKeyCallback returns the HostKeyCallback to use for a connection,
+// honoring --insecure and --known-hosts.
+func hostKeyCallback(opts AuthOptions) (ssh.HostKeyCallback, error) {
+	if opts.Insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path := opts.KnownHostsFile
+	if path == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	} else {
+		expanded, err := homedir.Expand(path)
+		if err != nil {
+			return nil, err
+		}
+		path = expanded
+	}
+
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("load known_hosts %s: %w (use --insecure to skip host key verification)", path, err)
+	}
+
+	return callback, nil
+}
+
+// Close close the session & connection, and any bastions dialed along
+// the way to reach it.
+func (s *Session) Close() {
+	if s.session != nil {
+		s.session.Close()
+	}
+
+	if s.conn != nil {
+		s.conn.Close()
+	}
+
+	for i := len(s.jumpClients) - 1; i >= 0; i-- {
+		s.jumpClients[i].Close()
+	}
+}
+
+// jumpHop is one hop of a "user@host:port" ProxyJump-style chain.
+type jumpHop struct {
+	user string
+	host string
+	port string
+}
+
+// parseJumpChain parses a comma-separated "user@host:port,..." jump
+// chain (mirroring OpenSSH's ProxyJump), in the order hops are dialed:
+// the first entry is reached directly, and each subsequent entry (and
+// finally the real target) is reached through the one before it.
+// defaultUser and defaultPort fill in any hop that omits them.
+func parseJumpChain(spec, defaultUser, defaultPort string) []jumpHop {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil
+	}
+
+	var hops []jumpHop
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		hops = append(hops, parseHop(part, defaultUser, defaultPort))
+	}
+	return hops
+}
+
+// parseHop parses a single "user@host:port" hop, falling back to
+// defaultUser and defaultPort for whichever parts are omitted.
+func parseHop(spec, defaultUser, defaultPort string) jumpHop {
+	hop := jumpHop{user: defaultUser, port: defaultPort}
+
+	rest := spec
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		hop.user = spec[:at]
+		rest = spec[at+1:]
+	}
+
+	if host, port, err := net.SplitHostPort(rest); err == nil {
+		hop.host, hop.port = host, port
+	} else {
+		hop.host = rest
+	}
+
+	return hop
+}
+
+// GetCrontab runs `crontab -l` and returns its output. If ctx is
+// cancelled or its deadline elapses before the command finishes, the
+// session (and its connection) is closed to unblock the in-flight
+// Output call, and ctx.Err() is returned.
+func (s *Session) GetCrontab(ctx context.Context) ([]byte, error) {
+	type result struct {
+		out []byte
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		out, err := s.session.Output("crontab -l\n")
+		done <- result{out, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-ctx.Done():
+		s.Close()
+		return nil, ctx.Err()
+	}
+}
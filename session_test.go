@@ -0,0 +1,577 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testServer is an in-process SSH server that accepts a single known
+// public key and replies to any exec request with a fixed crontab-like
+// payload, so the AuthMethod chain in NewSession can be exercised
+// end-to-end without a real host.
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func startTestServer(t *testing.T, wantKey ssh.PublicKey) *testServer {
+	t.Helper()
+
+	hostSigner, err := ssh.NewSignerFromKey(generateTestKey(t))
+	if err != nil {
+		t.Fatalf("host key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), wantKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleTestConn(nConn, config)
+		}
+	}()
+
+	return &testServer{addr: listener.Addr().String(), hostKey: hostSigner}
+}
+
+// directTCPIPMsg is the payload of a "direct-tcpip" channel open
+// request, as used by ssh.Client.Dial (and thus by our jump-host
+// dialing code) to ask a server to forward a connection.
+type directTCPIPMsg struct {
+	Host     string
+	Port     uint32
+	OrigHost string
+	OrigPort uint32
+}
+
+func handleTestConn(nConn net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go func() {
+				for req := range requests {
+					if req.Type == "exec" {
+						channel.Write([]byte("* * * * * /bin/true\n"))
+						req.Reply(true, nil)
+						channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+						channel.Close()
+					} else {
+						req.Reply(false, nil)
+					}
+				}
+			}()
+		case "direct-tcpip":
+			var msg directTCPIPMsg
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &msg); err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, "malformed forward request")
+				continue
+			}
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go forward(channel, fmt.Sprintf("%s:%d", msg.Host, msg.Port))
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+// forward proxies channel to a TCP connection to addr, acting as the
+// bastion side of a "direct-tcpip" (ProxyJump) forward.
+func forward(channel ssh.Channel, addr string) {
+	defer channel.Close()
+
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer target.Close()
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(target, channel); done <- struct{}{} }()
+	go func() { io.Copy(channel, target); done <- struct{}{} }()
+	<-done
+}
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func writeKnownHosts(t *testing.T, addr string, hostKey ssh.PublicKey) string {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("split host port: %v", err)
+	}
+
+	line := knownHostsLine(host, port, hostKey)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+	return path
+}
+
+func knownHostsLine(host, port string, key ssh.PublicKey) string {
+	addr := host
+	if port != "22" {
+		addr = fmt.Sprintf("[%s]:%s", host, port)
+	}
+	return fmt.Sprintf("%s %s", addr, strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(key)), "\n"))
+}
+
+func writePEMKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	block := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+
+	path := filepath.Join(t.TempDir(), "id_rsa")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write private key: %v", err)
+	}
+	return path
+}
+
+func dialHostPort(addr string) (string, string) {
+	host, port, _ := net.SplitHostPort(addr)
+	return host, port
+}
+
+func TestNewSession_PrivateKeyAuth(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startTestServer(t, clientSigner.PublicKey())
+	knownHosts := writeKnownHosts(t, srv.addr, srv.hostKey.PublicKey())
+	keyPath := writePEMKey(t, clientKey)
+
+	host, port := dialHostPort(srv.addr)
+	session, err := NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		PrivateKey:     keyPath,
+		KnownHostsFile: knownHosts,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	out, err := session.GetCrontab(context.Background())
+	if err != nil {
+		t.Fatalf("GetCrontab: %v", err)
+	}
+	if string(out) != "* * * * * /bin/true\n" {
+		t.Fatalf("unexpected crontab output: %q", out)
+	}
+}
+
+func TestNewSession_ExpandsTildeInPaths(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startTestServer(t, clientSigner.PublicKey())
+
+	// Point HOME at a scratch dir and place the key/known_hosts under
+	// it, so "~/..." values (as produced by inventory.LoadSSHConfig for
+	// a real IdentityFile/UserKnownHostsFile directive) only resolve if
+	// NewSession actually expands the tilde.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	keyPath := filepath.Join(home, "id_rsa")
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(clientKey)}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write private key: %v", err)
+	}
+
+	knownHostsPath := filepath.Join(home, "known_hosts")
+	host, port := dialHostPort(srv.addr)
+	line := knownHostsLine(host, port, srv.hostKey.PublicKey())
+	if err := os.WriteFile(knownHostsPath, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+
+	session, err := NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		PrivateKey:     "~/id_rsa",
+		KnownHostsFile: "~/known_hosts",
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+}
+
+func TestNewSession_AgentAuth(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startTestServer(t, clientSigner.PublicKey())
+	knownHosts := writeKnownHosts(t, srv.addr, srv.hostKey.PublicKey())
+
+	sockPath := startTestAgent(t, clientKey)
+	t.Setenv("SSH_AUTH_SOCK", sockPath)
+
+	host, port := dialHostPort(srv.addr)
+	session, err := NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		UseAgent:       true,
+		KnownHostsFile: knownHosts,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	if _, err := session.GetCrontab(context.Background()); err != nil {
+		t.Fatalf("GetCrontab: %v", err)
+	}
+}
+
+func TestNewSession_AgentAuth_FallsBackOnStaleSocket(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startTestServer(t, clientSigner.PublicKey())
+	knownHosts := writeKnownHosts(t, srv.addr, srv.hostKey.PublicKey())
+	keyPath := writePEMKey(t, clientKey)
+
+	// A stale SSH_AUTH_SOCK (dead agent, leftover tmux env, forwarded
+	// socket from a closed session) must fall back to the private key
+	// rather than aborting the whole auth chain.
+	t.Setenv("SSH_AUTH_SOCK", filepath.Join(t.TempDir(), "no-such-agent.sock"))
+
+	host, port := dialHostPort(srv.addr)
+	session, err := NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		UseAgent:       true,
+		PrivateKey:     keyPath,
+		KnownHostsFile: knownHosts,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	if _, err := session.GetCrontab(context.Background()); err != nil {
+		t.Fatalf("GetCrontab: %v", err)
+	}
+}
+
+func TestNewSession_UnknownHostKeyRejected(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startTestServer(t, clientSigner.PublicKey())
+	keyPath := writePEMKey(t, clientKey)
+
+	// Empty known_hosts file: the server's host key is unknown to us.
+	emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(emptyKnownHosts, nil, 0o600); err != nil {
+		t.Fatalf("write known_hosts: %v", err)
+	}
+
+	host, port := dialHostPort(srv.addr)
+	_, err = NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		PrivateKey:     keyPath,
+		KnownHostsFile: emptyKnownHosts,
+	}, 0)
+	if err == nil {
+		t.Fatal("expected error for unknown host key, got nil")
+	}
+}
+
+func TestNewSession_Insecure(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startTestServer(t, clientSigner.PublicKey())
+	keyPath := writePEMKey(t, clientKey)
+
+	host, port := dialHostPort(srv.addr)
+	session, err := NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		PrivateKey: keyPath,
+		Insecure:   true,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+}
+
+func TestNewSession_JumpHost(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+	keyPath := writePEMKey(t, clientKey)
+
+	target := startTestServer(t, clientSigner.PublicKey())
+	targetHost, targetPort := dialHostPort(target.addr)
+
+	bastion := startTestServer(t, clientSigner.PublicKey())
+	bastionHost, bastionPort := dialHostPort(bastion.addr)
+
+	dest := Dest{
+		Host: targetHost,
+		User: "test",
+		Port: targetPort,
+		Jump: fmt.Sprintf("test@%s:%s", bastionHost, bastionPort),
+	}
+
+	session, err := NewSession(dest, targetPort, AuthOptions{
+		PrivateKey: keyPath,
+		Insecure:   true,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	out, err := session.GetCrontab(context.Background())
+	if err != nil {
+		t.Fatalf("GetCrontab: %v", err)
+	}
+	if string(out) != "* * * * * /bin/true\n" {
+		t.Fatalf("unexpected crontab output: %q", out)
+	}
+}
+
+// TestNewSession_ChainedJumpHosts exercises a 2-hop "a->b->c" chain:
+// the target is reached through bastion2, which is itself reached
+// through bastion1, covering parseJumpChain/NewSession's loop beyond a
+// single hop.
+func TestNewSession_ChainedJumpHosts(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+	keyPath := writePEMKey(t, clientKey)
+
+	target := startTestServer(t, clientSigner.PublicKey())
+	targetHost, targetPort := dialHostPort(target.addr)
+
+	bastion2 := startTestServer(t, clientSigner.PublicKey())
+	bastion2Host, bastion2Port := dialHostPort(bastion2.addr)
+
+	bastion1 := startTestServer(t, clientSigner.PublicKey())
+	bastion1Host, bastion1Port := dialHostPort(bastion1.addr)
+
+	dest := Dest{
+		Host: targetHost,
+		User: "test",
+		Port: targetPort,
+		Jump: fmt.Sprintf("test@%s:%s,test@%s:%s", bastion1Host, bastion1Port, bastion2Host, bastion2Port),
+	}
+
+	session, err := NewSession(dest, targetPort, AuthOptions{
+		PrivateKey: keyPath,
+		Insecure:   true,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	out, err := session.GetCrontab(context.Background())
+	if err != nil {
+		t.Fatalf("GetCrontab: %v", err)
+	}
+	if string(out) != "* * * * * /bin/true\n" {
+		t.Fatalf("unexpected crontab output: %q", out)
+	}
+}
+
+// startTestAgent serves clientKey over an in-memory ssh-agent listening
+// on a temporary unix socket and returns the socket path.
+func startTestAgent(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("add key to agent: %v", err)
+	}
+
+	sockPath := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	return sockPath
+}
+
+// startSlowTestServer is like startTestServer, except it waits delay
+// before replying to an "exec" request, so tests can exercise
+// GetCrontab's ctx-cancellation path.
+func startSlowTestServer(t *testing.T, wantKey ssh.PublicKey, delay time.Duration) *testServer {
+	t.Helper()
+
+	hostSigner, err := ssh.NewSignerFromKey(generateTestKey(t))
+	if err != nil {
+		t.Fatalf("host key: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), wantKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(nConn net.Conn) {
+				conn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				go ssh.DiscardRequests(reqs)
+
+				for newChannel := range chans {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range requests {
+							if req.Type != "exec" {
+								req.Reply(false, nil)
+								continue
+							}
+							time.Sleep(delay)
+							channel.Write([]byte("* * * * * /bin/true\n"))
+							req.Reply(true, nil)
+							channel.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+							channel.Close()
+						}
+					}()
+				}
+			}(nConn)
+		}
+	}()
+
+	return &testServer{addr: listener.Addr().String(), hostKey: hostSigner}
+}
+
+func TestGetCrontab_ContextTimeout(t *testing.T) {
+	clientKey := generateTestKey(t)
+	clientSigner, err := ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("client signer: %v", err)
+	}
+
+	srv := startSlowTestServer(t, clientSigner.PublicKey(), time.Second)
+	keyPath := writePEMKey(t, clientKey)
+
+	host, port := dialHostPort(srv.addr)
+	session, err := NewSession(Dest{Host: host, User: "test"}, port, AuthOptions{
+		PrivateKey: keyPath,
+		Insecure:   true,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := session.GetCrontab(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetCrontab() error = %v, want context.DeadlineExceeded", err)
+	}
+}